@@ -17,6 +17,8 @@ limitations under the License.
 package clusterprovisioner
 
 import (
+	"context"
+
 	eventingv1alpha1 "github.com/knative/eventing/pkg/apis/eventing/v1alpha1"
 	"github.com/knative/eventing/pkg/buses/eventing/stub/channel"
 	"github.com/knative/eventing/pkg/sidecar/multichannelfanout"
@@ -35,8 +37,15 @@ const (
 	// controllerAgentName is the string used by this controller to identify
 	// itself when creating events.
 	controllerAgentName = "stub-bus-cluster-provisioner-controller"
+
+	// clusterProvisionerName is the name of the ClusterProvisioner this controller reconciles,
+	// and the value DispatcherLabels() is keyed on to find this bus' dispatcher pods.
+	clusterProvisionerName = "stub"
 )
 
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=endpoints,verbs=get;list;watch
+
 // ProvideController returns a flow controller.
 func ProvideController(mgr manager.Manager, logger *zap.Logger) (controller.Controller, http.Handler, error) {
 	logger = logger.With(zap.String("controller", controllerAgentName))
@@ -46,13 +55,14 @@ func ProvideController(mgr manager.Manager, logger *zap.Logger) (controller.Cont
 		logger.Error("Unable to create HTTP handler", zap.Error(err))
 		return nil, nil, err
 	}
+	prober := newDispatcherProber(mgr.GetCache(), logger)
 
 	// Setup a new controller to Reconcile ClusterProvisioners that are Stub buses.
 	r :=  &reconciler{
 		mgr: mgr,
 		recorder: mgr.GetRecorder(controllerAgentName),
 		logger: logger,
-		swapHttpHandlerConfig: swapHttpHandlerConfig(h, sync.Mutex{}),
+		swapHttpHandlerConfig: swapHttpHandlerConfig(h, sync.Mutex{}, prober),
 		channelControllers: make(map[corev1.ObjectReference]*channel.ConfigAndStopCh),
 	}
 	c, err := controller.New(controllerAgentName, mgr, controller.Options{
@@ -78,7 +88,14 @@ func ProvideController(mgr manager.Manager, logger *zap.Logger) (controller.Cont
 	return c, h, nil
 }
 
-func swapHttpHandlerConfig(s *swappable.Handler, sLock sync.Mutex) func(multichannelfanout.Config) error {
+// subscriptionProber is the subset of *dispatcherProber that swapHttpHandlerConfig needs, so
+// tests can swap in a fake instead of polling real dispatcher pods.
+type subscriptionProber interface {
+	waitForConfig(ctx context.Context, ccpName string, config multichannelfanout.Config) error
+}
+
+func swapHttpHandlerConfig(s *swappable.Handler, sLock sync.Mutex, prober subscriptionProber) func(multichannelfanout.Config) error {
+	var probedConfig multichannelfanout.Config
 	return func(config multichannelfanout.Config) error {
 		sLock.Lock()
 		defer sLock.Unlock()
@@ -90,6 +107,21 @@ func swapHttpHandlerConfig(s *swappable.Handler, sLock sync.Mutex) func(multicha
 			}
 			s.SetMultiChannelFanoutHandler(newH)
 		}
+
+		// Don't let the caller mark anything Ready until every dispatcher pod has actually
+		// loaded this Config; otherwise there is a window where the control plane reports Ready
+		// but some replicas are still serving the old Subscriptions. Keep retrying the probe on
+		// every reconcile until it succeeds, even when this reconcile found no diff: if a
+		// previous call already swapped the Handler and then the probe timed out, ConfigDiff
+		// will never be non-empty for this config again, and the probe would otherwise never
+		// get retried.
+		if probedConfig.ConfigDiff(config) == "" {
+			return nil
+		}
+		if err := prober.waitForConfig(context.Background(), clusterProvisionerName, config); err != nil {
+			return err
+		}
+		probedConfig = config
 		return nil
 	}
 }
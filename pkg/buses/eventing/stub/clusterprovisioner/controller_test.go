@@ -0,0 +1,85 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterprovisioner
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/knative/eventing/pkg/sidecar/multichannelfanout"
+	"github.com/knative/eventing/pkg/sidecar/swappable"
+	"go.uber.org/zap"
+)
+
+// fakeProber is a subscriptionProber whose waitForConfig result is controlled by the test, so
+// probe timeouts and recoveries can be simulated without polling real dispatcher pods.
+type fakeProber struct {
+	mu    sync.Mutex
+	calls int
+	err   error
+}
+
+func (f *fakeProber) waitForConfig(context.Context, string, multichannelfanout.Config) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	return f.err
+}
+
+func TestSwapHttpHandlerConfig_ProbeRetriedUntilItSucceeds(t *testing.T) {
+	h, err := swappable.NewEmptyHandler(zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewEmptyHandler() = %v", err)
+	}
+	prober := &fakeProber{err: errors.New("timed out waiting for dispatcher pods")}
+	swap := swapHttpHandlerConfig(h, sync.Mutex{}, prober)
+
+	config := multichannelfanout.Config{
+		ChannelConfigs: []multichannelfanout.ChannelConfig{
+			{Namespace: "ns", Name: "my-channel", HostName: "my-channel.ns.svc.cluster.local"},
+		},
+	}
+
+	if err := swap(config); err == nil {
+		t.Fatal("swap() = nil, want error from a timed out probe")
+	}
+	if prober.calls != 1 {
+		t.Fatalf("probe calls = %d, want 1", prober.calls)
+	}
+
+	// The next reconcile observes the same Config (ConfigDiff == ""), since the Handler was
+	// already swapped on the previous call. The probe must still be retried: it never actually
+	// succeeded for this Config.
+	prober.err = nil
+	if err := swap(config); err != nil {
+		t.Fatalf("swap() = %v, want nil", err)
+	}
+	if prober.calls != 2 {
+		t.Fatalf("probe calls = %d, want 2 (probe should be retried on an unchanged Config)", prober.calls)
+	}
+
+	// Once the probe has succeeded for this Config, further reconciles of the same Config must
+	// not probe again.
+	if err := swap(config); err != nil {
+		t.Fatalf("swap() = %v, want nil", err)
+	}
+	if prober.calls != 2 {
+		t.Fatalf("probe calls = %d, want 2 (already-probed Config should not be re-probed)", prober.calls)
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterprovisioner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/knative/eventing/pkg/provisioners"
+	"github.com/knative/eventing/pkg/sidecar/multichannelfanout"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// probeRetryInterval is how long dispatcherProber waits between polling attempts for a pod
+	// that hasn't yet reported a Subscription as loaded.
+	probeRetryInterval = 500 * time.Millisecond
+	// probeTimeout bounds how long dispatcherProber will wait for every dispatcher pod to
+	// report a single Subscription as loaded before giving up.
+	probeTimeout = 30 * time.Second
+)
+
+// dispatcherProber asks every dispatcher pod for a ClusterProvisioner whether it has loaded a
+// particular Subscription yet, closing the window where the control plane marks a Subscription
+// Ready before every data-plane replica has actually started dispatching to it.
+type dispatcherProber struct {
+	reader     client.Reader
+	httpClient *http.Client
+	logger     *zap.Logger
+}
+
+func newDispatcherProber(reader client.Reader, logger *zap.Logger) *dispatcherProber {
+	return &dispatcherProber{
+		reader:     reader,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		logger:     logger,
+	}
+}
+
+// waitForConfig blocks until every dispatcher pod for ccpName reports every Subscription in
+// config as loaded, or probeTimeout elapses while waiting on any one Subscription. The timeout
+// restarts for each Subscription, so the total wait is not bounded by probeTimeout alone.
+func (p *dispatcherProber) waitForConfig(ctx context.Context, ccpName string, config multichannelfanout.Config) error {
+	for _, cc := range config.ChannelConfigs {
+		channel := corev1.ObjectReference{Namespace: cc.Namespace, Name: cc.Name}
+		for _, sub := range cc.FanoutConfig.Subscriptions {
+			if err := p.waitForSubscription(ctx, ccpName, channel, string(sub.UID)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// waitForSubscription blocks until every dispatcher pod for ccpName reports subUID, within
+// channel, as loaded.
+func (p *dispatcherProber) waitForSubscription(ctx context.Context, ccpName string, channel corev1.ObjectReference, subUID string) error {
+	deadline := time.Now().Add(probeTimeout)
+	for {
+		ready, err := p.allDispatchersReady(ctx, ccpName, channel, subUID)
+		if err != nil {
+			return err
+		}
+		if ready {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for all %q dispatcher pods to report subscription %s/%s ready", ccpName, channel.Name, subUID)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(probeRetryInterval):
+		}
+	}
+}
+
+func (p *dispatcherProber) allDispatchersReady(ctx context.Context, ccpName string, channel corev1.ObjectReference, subUID string) (bool, error) {
+	pods := &corev1.PodList{}
+	if err := p.reader.List(ctx, &client.ListOptions{
+		LabelSelector: labels.SelectorFromSet(provisioners.DispatcherLabels(ccpName)),
+	}, pods); err != nil {
+		return false, fmt.Errorf("unable to list dispatcher pods: %v", err)
+	}
+	if len(pods.Items) == 0 {
+		return false, nil
+	}
+	for _, pod := range pods.Items {
+		ready, err := p.probePod(pod, channel, subUID)
+		if err != nil {
+			p.logger.Debug("Error probing dispatcher pod", zap.String("pod", pod.Name), zap.Error(err))
+			return false, nil
+		}
+		if !ready {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (p *dispatcherProber) probePod(pod corev1.Pod, channel corev1.ObjectReference, subUID string) (bool, error) {
+	if pod.Status.PodIP == "" {
+		return false, nil
+	}
+	url := fmt.Sprintf("http://%s%s?channel=%s/%s&sub=%s", pod.Status.PodIP, multichannelfanout.ProbePath, channel.Namespace, channel.Name, subUID)
+	resp, err := p.httpClient.Get(url)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK, nil
+}
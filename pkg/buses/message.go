@@ -0,0 +1,32 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buses
+
+// ChannelReference is a reference to a Channel, identifying it uniquely within the cluster.
+type ChannelReference struct {
+	Namespace string
+	Name      string
+}
+
+// Message is the canonical in-memory representation of an event as it moves through a bus: the
+// headers and body of the HTTP request used to write the event, so that it can be replayed as an
+// HTTP request to subscribers. Headers holds one value per header name; any further values of a
+// repeated header are not kept.
+type Message struct {
+	Headers map[string]string
+	Payload []byte
+}
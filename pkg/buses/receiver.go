@@ -0,0 +1,87 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buses
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// ReceiverFunc is invoked for every Message read off the wire, keyed by the ChannelReference it
+// was addressed to. Returning an error rejects the Message, causing HandleRequest to fail.
+type ReceiverFunc func(ChannelReference, *Message) error
+
+// MessageReceiver parses an inbound HTTP request into a ChannelReference and a Message, and
+// hands it off to a ReceiverFunc for further handling (e.g. fanning it out to subscribers).
+type MessageReceiver struct {
+	receiverFunc ReceiverFunc
+	logger       *zap.SugaredLogger
+}
+
+// NewMessageReceiver creates a MessageReceiver that calls receiverFunc for every accepted
+// Message.
+func NewMessageReceiver(receiverFunc ReceiverFunc, logger *zap.SugaredLogger) *MessageReceiver {
+	return &MessageReceiver{
+		receiverFunc: receiverFunc,
+		logger:       logger,
+	}
+}
+
+// HandleRequest parses r into a ChannelReference and a Message, then invokes the
+// MessageReceiver's ReceiverFunc. The ChannelReference is derived from the request's Host header,
+// in the form '<name>.<namespace>', optionally followed by further domain labels.
+func (r *MessageReceiver) HandleRequest(req *http.Request) (ChannelReference, *Message, error) {
+	ref, err := channelReferenceFromHost(req.Host)
+	if err != nil {
+		return ChannelReference{}, nil, err
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return ChannelReference{}, nil, fmt.Errorf("unable to read request body: %v", err)
+	}
+	defer req.Body.Close()
+
+	headers := make(map[string]string, len(req.Header))
+	for k := range req.Header {
+		headers[k] = req.Header.Get(k)
+	}
+	message := &Message{
+		Headers: headers,
+		Payload: body,
+	}
+
+	if err := r.receiverFunc(ref, message); err != nil {
+		return ChannelReference{}, nil, err
+	}
+	return ref, message, nil
+}
+
+func channelReferenceFromHost(host string) (ChannelReference, error) {
+	chunks := strings.Split(host, ".")
+	if len(chunks) < 2 {
+		return ChannelReference{}, fmt.Errorf("bad host format %q, expected '<name>.<namespace>...'", host)
+	}
+	return ChannelReference{
+		Name:      chunks[0],
+		Namespace: chunks[1],
+	}, nil
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fanout
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+)
+
+// ClientOptions configures the HTTP client each per-subscription CloudEvents protocol that fanout
+// dispatches through is built with. Unless Transport is set, every subscriber domain gets its own
+// transport. Raw (non-CloudEvent) dispatches and dead letter POSTs do not use these options.
+type ClientOptions struct {
+	// Timeout bounds a single CloudEvents dispatch HTTP call.
+	Timeout time.Duration
+	// MaxIdleConnsPerHost bounds how many idle keep-alive connections are kept open per
+	// subscriber, so a busy Channel doesn't pay connection setup cost for every event.
+	MaxIdleConnsPerHost int
+	// Transport overrides the http.RoundTripper used by every CloudEvents dispatch. Mostly useful
+	// for tests; production code should leave this nil and use MaxIdleConnsPerHost instead.
+	Transport http.RoundTripper
+}
+
+// DefaultClientOptions is used wherever a Handler is wired up without caller-supplied
+// ClientOptions.
+var DefaultClientOptions = ClientOptions{
+	Timeout:             60 * time.Second,
+	MaxIdleConnsPerHost: 100,
+}
+
+func (o ClientOptions) httpClient() *http.Client {
+	transport := o.Transport
+	if transport == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.MaxIdleConnsPerHost = o.MaxIdleConnsPerHost
+		transport = t
+	}
+	return &http.Client{Transport: transport, Timeout: o.Timeout}
+}
+
+// ceClientPool lazily creates, and caches, one cehttp.Protocol per subscriber domain, so that the
+// underlying keep-alive connections are reused across events instead of being torn down after
+// every dispatch.
+//
+// Dispatch talks to the protocol directly rather than wrapping it in a cloudevents.Client: the
+// Client's Request only ever hands back the reply decoded as an Event, discarding the literal
+// HTTP response headers (Traceparent, Prefer, any allow-listed extension header) that never made
+// it into the Event's own attributes.
+type ceClientPool struct {
+	opts ClientOptions
+
+	mu        sync.Mutex
+	protocols map[string]*cehttp.Protocol
+}
+
+func newCEClientPool(opts ClientOptions) *ceClientPool {
+	return &ceClientPool{
+		opts:      opts,
+		protocols: make(map[string]*cehttp.Protocol),
+	}
+}
+
+func (p *ceClientPool) protocolFor(domain string) (*cehttp.Protocol, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if t, ok := p.protocols[domain]; ok {
+		return t, nil
+	}
+
+	t, err := cehttp.New(
+		cehttp.WithTarget(fmt.Sprintf("http://%s/", domain)),
+		cehttp.WithClient(*p.opts.httpClient()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create CloudEvents HTTP transport for %q: %v", domain, err)
+	}
+	p.protocols[domain] = t
+	return t, nil
+}
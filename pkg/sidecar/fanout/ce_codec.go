@@ -0,0 +1,94 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fanout
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+)
+
+// decodeEvent turns a raw header/body pair back into a CloudEvent, so the dispatch path can
+// re-encode it for the next hop with proper binding-mode negotiation instead of forwarding
+// opaque bytes. It fails if header/body don't carry a recognizable binary- or structured-mode
+// CloudEvent (no ce-specversion header and no application/cloudevents+json content type).
+func decodeEvent(header http.Header, body []byte) (*cloudevents.Event, error) {
+	msg := cehttp.NewMessage(header, ioutil.NopCloser(bytes.NewReader(body)))
+	defer msg.Finish(nil)
+	event, err := binding.ToEvent(context.Background(), msg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode CloudEvent: %v", err)
+	}
+	return event, nil
+}
+
+// eventHeaderAndBody flattens a CloudEvent's standard attributes and extensions into the
+// Ce-* header shape that dispatchOne already knows how to merge and filter, so chaining a reply
+// CloudEvent into the next hop doesn't require touching any of the existing retry, allow-list,
+// or dead-letter logic.
+func eventHeaderAndBody(event *cloudevents.Event) (http.Header, []byte) {
+	header := make(http.Header)
+	header.Set("Ce-Id", event.ID())
+	header.Set("Ce-Source", event.Source())
+	header.Set("Ce-Type", event.Type())
+	header.Set("Ce-Specversion", event.SpecVersion())
+	if event.DataContentType() != "" {
+		header.Set("Content-Type", event.DataContentType())
+	}
+	for name, value := range event.Extensions() {
+		header.Set("Ce-"+name, fmt.Sprintf("%v", value))
+	}
+	return header, event.Data()
+}
+
+// decodeReply pulls the headers and body back out of a reply binding.Message from the CloudEvents
+// HTTP protocol. The literal transport header is kept as-is, since it carries whatever the
+// subscriber actually set (Traceparent, Prefer, a custom allow-listed header, ...), and is
+// overlaid with the reply CloudEvent's own attributes, so Ce-* headers are present even for a
+// structured-mode reply where they never appear as literal HTTP headers.
+func decodeReply(ctx context.Context, msg binding.Message) (http.Header, []byte) {
+	header := http.Header{}
+	if httpMsg, ok := msg.(*cehttp.Message); ok {
+		header = cloneHeader(httpMsg.Header)
+	}
+	event, err := binding.ToEvent(ctx, msg)
+	if err != nil {
+		return header, nil
+	}
+	ceHeader, body := eventHeaderAndBody(event)
+	return mergeHeaders(header, ceHeader), body
+}
+
+// httpResult extracts the response status code a cehttp.Protocol.Request observed, if the
+// underlying transport was HTTP. ok is false for a transport-level failure (no response was ever
+// received), which callers should treat the same way a raw net/http transport error is treated.
+func httpResult(result error) (status int, ok bool) {
+	var httpResult *cehttp.Result
+	if cloudevents.ResultAs(result, &httpResult) {
+		return httpResult.StatusCode, true
+	}
+	if cloudevents.IsACK(result) {
+		return http.StatusOK, true
+	}
+	return 0, false
+}
@@ -0,0 +1,555 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fanout provides an http.Handler that takes in one request and fans it out to N other
+// requests, based on a list of Subscriptions. Logically, it represents all the Subscriptions to
+// a single Channel.
+package fanout
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/knative/eventing/pkg/buses"
+	duckv1alpha1 "github.com/knative/pkg/apis/duck/v1alpha1"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultTimeout is used when Config does not specify a timeout for the whole fan-out
+	// operation.
+	defaultTimeout = 60 * time.Second
+
+	// maxDeadLetterBodyBytes bounds how much of a failed response body is copied into the
+	// dead letter metadata, so that a chatty subscriber can't blow up the DLQ payload.
+	maxDeadLetterBodyBytes = 1024
+)
+
+// BackoffPolicy is the retry backoff policy for a DeliverySpec.
+type BackoffPolicy string
+
+const (
+	// BackoffPolicyLinear increases the backoff by a fixed step (the initial backoff) on every
+	// attempt.
+	BackoffPolicyLinear BackoffPolicy = "linear"
+	// BackoffPolicyExponential doubles the backoff on every attempt.
+	BackoffPolicyExponential BackoffPolicy = "exponential"
+)
+
+// DefaultDeliverySpec is used for any subscription that does not carry its own DeliverySpec. It
+// preserves the historical behaviour of a single attempt with no dead letter sink.
+var DefaultDeliverySpec = DeliverySpec{
+	MaxAttempts: 1,
+}
+
+// DeliverySpec describes how a single subscription's delivery should be retried, and where to
+// send the event if it is never successfully delivered.
+type DeliverySpec struct {
+	// MaxAttempts is the total number of times delivery will be attempted, including the first
+	// attempt. A value <= 0 is treated as 1.
+	MaxAttempts int
+	// BackoffPolicy controls how InitialBackoff grows between attempts. Defaults to
+	// BackoffPolicyLinear.
+	BackoffPolicy BackoffPolicy
+	// InitialBackoff is the delay before the second attempt.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts. A value <= 0 means unbounded.
+	MaxBackoff time.Duration
+	// DeadLetterSink is the URL an event is POSTed to, along with failure metadata, once
+	// MaxAttempts have been exhausted or a non-retryable response is received. An empty value
+	// means delivery failures are surfaced directly to the caller instead of being
+	// dead-lettered.
+	DeadLetterSink string
+}
+
+func (d DeliverySpec) maxAttempts() int {
+	if d.MaxAttempts <= 0 {
+		return 1
+	}
+	return d.MaxAttempts
+}
+
+func (d DeliverySpec) nextBackoff(attempt int) time.Duration {
+	var next time.Duration
+	switch d.BackoffPolicy {
+	case BackoffPolicyExponential:
+		next = d.InitialBackoff
+		for i := 1; i < attempt; i++ {
+			next *= 2
+		}
+	default:
+		next = d.InitialBackoff * time.Duration(attempt)
+	}
+	if d.MaxBackoff > 0 && next > d.MaxBackoff {
+		next = d.MaxBackoff
+	}
+	return next
+}
+
+// Config holds the set of Subscriptions fanned out to for a single Channel, along with the
+// per-subscription delivery behaviour.
+type Config struct {
+	Subscriptions []duckv1alpha1.ChannelSubscriberSpec
+
+	// Deliveries holds the DeliverySpec for the Subscription at the same index. A missing or
+	// nil entry falls back to DefaultDeliverySpec. Deliveries may be shorter than Subscriptions
+	// or nil entirely.
+	Deliveries []*DeliverySpec
+
+	// AllowedReplyHeaders lists additional header names, beyond the package default, that may be
+	// proxied from a Callable's reply to the Sinkable.
+	AllowedReplyHeaders []string
+}
+
+func (c Config) deliverySpec(i int) DeliverySpec {
+	if i < len(c.Deliveries) && c.Deliveries[i] != nil {
+		return *c.Deliveries[i]
+	}
+	return DefaultDeliverySpec
+}
+
+// fanoutHandler is a http.Handler that takes in one request and fans it out to N other requests,
+// based on the subscriptions of a single Config. The response is written once all subscriptions
+// have either succeeded or been dead-lettered.
+type fanoutHandler struct {
+	config Config
+	logger *zap.Logger
+
+	// receiver parses each inbound request into a buses.ChannelReference and a buses.Message.
+	// HandleRequest returns the parsed Message directly, so ServeHTTP fans out from that return
+	// value instead of round-tripping it through shared state.
+	receiver *buses.MessageReceiver
+	timeout  time.Duration
+
+	httpClient *http.Client
+	allowList  HeaderProxyAllowList
+
+	// ceClients dispatches messages that decode as a well-formed CloudEvent through the
+	// CloudEvents SDK, which negotiates binary vs. structured binding-mode instead of always
+	// assuming one or the other. Messages that don't decode as a CloudEvent at all are still
+	// sent by httpClient, forwarding the raw message body unchanged.
+	ceClients *ceClientPool
+}
+
+// NewHandler creates a new fanout.Handler. opts configures the HTTP transport used to dispatch
+// CloudEvents to subscribers; callers that don't need anything non-default can pass
+// DefaultClientOptions.
+func NewHandler(logger *zap.Logger, config Config, opts ClientOptions) http.Handler {
+	handler := &fanoutHandler{
+		config:  config,
+		logger:  logger,
+		timeout: defaultTimeout,
+		httpClient: &http.Client{
+			Transport: http.DefaultTransport,
+		},
+		allowList: NewHeaderProxyAllowList(config.AllowedReplyHeaders),
+		ceClients: newCEClientPool(opts),
+	}
+	handler.receiver = buses.NewMessageReceiver(acceptMessage, logger.Sugar())
+	return handler
+}
+
+// acceptMessage is the buses.ReceiverFunc given to the MessageReceiver. It has nothing to
+// validate or record; HandleRequest already hands the parsed Message straight back to its
+// caller, so fanning out per-request never needs to share state across requests.
+func acceptMessage(buses.ChannelReference, *buses.Message) error {
+	return nil
+}
+
+func (f *fanoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, message, err := f.receiver.HandleRequest(r)
+	if err != nil {
+		f.logger.Error("Unable to handle request", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	defer cancel()
+	results := f.dispatch(ctx, message)
+	f.writeResponse(w, ctx, results)
+}
+
+// DispatchResult carries the outcome of fanning out to a single Subscription, so that callers
+// can tell a subscriber's 4xx apart from a transport error, a timeout, or any other failure, and
+// make their own retry vs. dead-letter decisions. Err is nil if the Subscription was either
+// delivered to successfully or dead-lettered.
+type DispatchResult struct {
+	// SubscriberRef identifies which Subscription this result is for, for aggregation into the
+	// Ce-Knative-Dispatch-Failure summary.
+	SubscriberRef  string
+	ResponseCode   int
+	ResponseBody   []byte
+	ResponseHeader http.Header
+	Err            error
+}
+
+// dispatch delivers (or dead-letters) message to every subscription independently, so that a
+// slow or failing subscriber does not hold up, or take down, delivery to the others. It returns
+// one DispatchResult per Subscription.
+func (f *fanoutHandler) dispatch(ctx context.Context, message *buses.Message) []DispatchResult {
+	subs := f.config.Subscriptions
+	if len(subs) == 0 {
+		return nil
+	}
+
+	resultCh := make(chan DispatchResult, len(subs))
+	for i, sub := range subs {
+		go func(i int, sub duckv1alpha1.ChannelSubscriberSpec) {
+			resultCh <- f.dispatchOne(ctx, sub, f.config.deliverySpec(i), message)
+		}(i, sub)
+	}
+
+	results := make([]DispatchResult, 0, len(subs))
+	for range subs {
+		results = append(results, <-resultCh)
+	}
+	return results
+}
+
+// dispatchOne delivers message to a single subscription, retrying according to spec until it
+// succeeds, is dead-lettered, or the DeliverySpec is exhausted. A Callable is a general HTTP
+// endpoint and may not be idempotent (it might process a payment, enqueue a side effect, ...), so
+// it is retried only while it fails: once it has replied successfully, every subsequent attempt
+// retries only the Sinkable leg against that cached reply, rather than re-invoking the Callable.
+func (f *fanoutHandler) dispatchOne(ctx context.Context, sub duckv1alpha1.ChannelSubscriberSpec, spec DeliverySpec, message *buses.Message) DispatchResult {
+	ref := subscriberRef(sub)
+	var lastStatus int
+	var lastHeader http.Header
+	var lastBody []byte
+	var lastErr error
+	attemptsMade := 0
+
+	if sub.CallableDomain == "" && sub.SinkableDomain == "" {
+		return DispatchResult{SubscriberRef: ref}
+	}
+
+	sinkHeaders, sinkBody := toHeader(message.Headers), message.Payload
+	callableDone := sub.CallableDomain == ""
+
+	maxAttempts := spec.maxAttempts()
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		attemptsMade = attempt
+
+		if !callableDone {
+			status, header, body, err := f.postMessage(ctx, sub.CallableDomain, sinkHeaders, sinkBody)
+			if err != nil || status >= 300 {
+				lastStatus, lastHeader, lastBody, lastErr = status, header, body, err
+				if attempt == maxAttempts || !isRetryable(status, err) {
+					break
+				}
+				if !f.waitForRetry(ctx, spec, attempt) {
+					lastErr = ctx.Err()
+					break
+				}
+				continue
+			}
+			callableDone = true
+			sinkHeaders = mergeHeaders(f.allowList.Filter(sinkHeaders), f.allowList.Filter(header))
+			sinkBody = body
+			if sub.SinkableDomain == "" {
+				return DispatchResult{SubscriberRef: ref, ResponseCode: status}
+			}
+			// Fall through to attempt the Sinkable leg in this same attempt.
+		}
+
+		status, header, body, err := f.postMessage(ctx, sub.SinkableDomain, sinkHeaders, sinkBody)
+		if err == nil && status < 300 {
+			return DispatchResult{SubscriberRef: ref, ResponseCode: status}
+		}
+		lastStatus, lastHeader, lastBody, lastErr = status, header, body, err
+
+		if attempt == maxAttempts || !isRetryable(status, err) {
+			break
+		}
+		if !f.waitForRetry(ctx, spec, attempt) {
+			lastErr = ctx.Err()
+			break
+		}
+	}
+
+	if spec.DeadLetterSink == "" {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("subscription %q failed with status %d", ref, lastStatus)
+		}
+		return DispatchResult{SubscriberRef: ref, ResponseCode: lastStatus, ResponseBody: lastBody, ResponseHeader: lastHeader, Err: lastErr}
+	}
+	if err := f.sendToDeadLetter(ctx, spec.DeadLetterSink, sub, message, attemptResult{
+		attempts: attemptsMade,
+		status:   lastStatus,
+		body:     lastBody,
+		err:      lastErr,
+	}); err != nil {
+		// The failure here is ours (the dead letter sink is unreachable or rejected the
+		// event), not the subscriber's, so there is no subscriber status code to propagate.
+		return DispatchResult{SubscriberRef: ref, Err: err}
+	}
+	return DispatchResult{SubscriberRef: ref, ResponseCode: lastStatus}
+}
+
+// dispatchFailureSummary is the JSON body of the Ce-Knative-Dispatch-Failure response header: a
+// short, structured list of which subscriptions failed and how, so that ingress callers (brokers,
+// other channels) can make their own retry vs. dead-letter decisions.
+type dispatchFailureSummary struct {
+	SubscriberRef string `json:"subscriberRef"`
+	ResponseCode  int    `json:"responseCode,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// writeResponse picks the outer HTTP status for results and writes it to w. A timed out fan-out
+// always reports 504. Otherwise, a single subscriber failure with a 4xx or 5xx status code
+// propagates that status code verbatim (echoing Retry-After for a 429), so the caller has the
+// same signal the subscriber gave us; anything else - multiple failures, or a failure we can't
+// attribute to a concrete status code - falls back to 500. Any failure also sets
+// Ce-Knative-Dispatch-Failure with a JSON summary of what failed.
+func (f *fanoutHandler) writeResponse(w http.ResponseWriter, ctx context.Context, results []DispatchResult) {
+	var failed []DispatchResult
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	if len(failed) == 0 {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	summary := make([]dispatchFailureSummary, len(failed))
+	for i, r := range failed {
+		s := dispatchFailureSummary{SubscriberRef: r.SubscriberRef, ResponseCode: r.ResponseCode}
+		if r.Err != nil {
+			s.Error = r.Err.Error()
+		}
+		summary[i] = s
+	}
+	if body, err := json.Marshal(summary); err == nil {
+		w.Header().Set("Ce-Knative-Dispatch-Failure", string(body))
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		w.WriteHeader(http.StatusGatewayTimeout)
+		return
+	}
+
+	status := http.StatusInternalServerError
+	if len(failed) == 1 && failed[0].ResponseCode >= 400 && failed[0].ResponseCode < 600 {
+		status = failed[0].ResponseCode
+		if status == http.StatusTooManyRequests {
+			if ra := failed[0].ResponseHeader.Get("Retry-After"); ra != "" {
+				w.Header().Set("Retry-After", ra)
+			}
+		}
+	}
+	w.WriteHeader(status)
+}
+
+// waitForRetry sleeps for attempt's backoff before the next retry, returning false (without
+// waiting out the rest of the backoff) if ctx is done first.
+func (f *fanoutHandler) waitForRetry(ctx context.Context, spec DeliverySpec, attempt int) bool {
+	select {
+	case <-time.After(spec.nextBackoff(attempt)):
+		return ctx.Err() == nil
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// isRetryable reports whether a dispatch attempt may succeed if retried: transport-level errors
+// (timeouts, connection resets) and the HTTP statuses a well-behaved subscriber uses to signal
+// that the failure is transient.
+func isRetryable(status int, err error) bool {
+	if err != nil {
+		return true
+	}
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// postMessage delivers payload to domain. If headers/payload decode as a CloudEvent, delivery
+// goes through the CloudEvents SDK, which sends binary-mode by default and falls back to
+// structured-mode if the subscriber answers 415 Unsupported Media Type. Not every producer in
+// this system emits well-formed CloudEvents yet, so anything that fails to decode is forwarded
+// unchanged over raw HTTP, exactly as it always has been.
+func (f *fanoutHandler) postMessage(ctx context.Context, domain string, headers http.Header, payload []byte) (int, http.Header, []byte, error) {
+	if event, err := decodeEvent(headers, payload); err == nil {
+		return f.postEvent(ctx, domain, event)
+	}
+	return f.postRaw(ctx, domain, headers, payload)
+}
+
+// postEvent delivers event to domain through a pooled cehttp.Protocol, negotiating binary vs.
+// structured binding-mode. Its return shape matches postRaw so callers don't need to know which
+// path was taken.
+func (f *fanoutHandler) postEvent(ctx context.Context, domain string, event *cloudevents.Event) (int, http.Header, []byte, error) {
+	protocol, err := f.ceClients.protocolFor(domain)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	status, header, body, err := f.requestEvent(ctx, protocol, event)
+	if status == http.StatusUnsupportedMediaType {
+		// The subscriber doesn't understand binary mode; retry once, forcing structured mode.
+		status, header, body, err = f.requestEvent(binding.WithForceStructured(ctx), protocol, event)
+	}
+	return status, header, body, err
+}
+
+// requestEvent sends event through protocol and decodes the reply, if any. It talks to the
+// protocol directly (rather than through a cloudevents.Client) so that decodeReply can recover
+// the literal HTTP response headers alongside whatever the reply CloudEvent's own attributes say.
+func (f *fanoutHandler) requestEvent(ctx context.Context, protocol *cehttp.Protocol, event *cloudevents.Event) (int, http.Header, []byte, error) {
+	outMsg := binding.ToMessage(event)
+	defer outMsg.Finish(nil)
+
+	replyMsg, result := protocol.Request(ctx, outMsg)
+	status, ok := httpResult(result)
+	if !ok {
+		return 0, nil, nil, result
+	}
+	if replyMsg == nil {
+		return status, http.Header{}, nil, nil
+	}
+	defer replyMsg.Finish(nil)
+
+	header, body := decodeReply(ctx, replyMsg)
+	return status, header, body, nil
+}
+
+func (f *fanoutHandler) postRaw(ctx context.Context, domain string, headers http.Header, payload []byte) (int, http.Header, []byte, error) {
+	url := fmt.Sprintf("http://%s/", domain)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header = cloneHeader(headers)
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, resp.Header, nil, err
+	}
+	return resp.StatusCode, resp.Header, body, nil
+}
+
+// toHeader converts the flattened header map carried by a buses.Message back into an
+// http.Header, so it can go through the same filtering and merging logic as a live HTTP
+// response.
+func toHeader(headers map[string]string) http.Header {
+	h := make(http.Header, len(headers))
+	for k, v := range headers {
+		h.Set(k, v)
+	}
+	return h
+}
+
+// mergeHeaders overlays override on top of base: any header present in override replaces base's
+// value for that header, and headers only present in base are kept as-is.
+func mergeHeaders(base, override http.Header) http.Header {
+	merged := cloneHeader(base)
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
+
+func cloneHeader(h http.Header) http.Header {
+	cloned := make(http.Header, len(h))
+	for k, v := range h {
+		cloned[k] = v
+	}
+	return cloned
+}
+
+type attemptResult struct {
+	attempts int
+	status   int
+	body     []byte
+	err      error
+}
+
+// deadLetterMetadata is attached (as JSON) to the event POSTed to a DeadLetterSink, so that
+// whatever is consuming the dead letter queue can tell why the event landed there.
+type deadLetterMetadata struct {
+	SubscriberURI string `json:"subscriberUri,omitempty"`
+	Attempts      int    `json:"attempts"`
+	LastStatus    int    `json:"lastStatus,omitempty"`
+	LastBody      string `json:"lastBody,omitempty"`
+	LastError     string `json:"lastError,omitempty"`
+}
+
+// sendToDeadLetter POSTs message to sink, annotated with why it failed to deliver normally. The
+// event's own headers (CE attributes, Content-Type, and anything else on the allow-list) are
+// forwarded alongside the failure metadata, so the dead-lettered request is still identifiable as
+// the event it represents. The outer dispatch only fails if this POST itself fails.
+func (f *fanoutHandler) sendToDeadLetter(ctx context.Context, sink string, sub duckv1alpha1.ChannelSubscriberSpec, message *buses.Message, result attemptResult) error {
+	meta := deadLetterMetadata{
+		SubscriberURI: subscriberRef(sub),
+		Attempts:      result.attempts,
+		LastStatus:    result.status,
+		LastBody:      truncate(result.body, maxDeadLetterBodyBytes),
+	}
+	if result.err != nil {
+		meta.LastError = result.err.Error()
+	}
+	metaJSON, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("unable to marshal dead letter metadata: %v", err)
+	}
+
+	url := fmt.Sprintf("http://%s/", sink)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(message.Payload))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header = f.allowList.Filter(toHeader(message.Headers))
+	req.Header.Set("Ce-Knative-Dlq-Info", string(metaJSON))
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("dead letter sink %q unreachable: %v", sink, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("dead letter sink %q returned status %d", sink, resp.StatusCode)
+	}
+	return nil
+}
+
+func subscriberRef(sub duckv1alpha1.ChannelSubscriberSpec) string {
+	if sub.SinkableDomain != "" {
+		return sub.SinkableDomain
+	}
+	return sub.CallableDomain
+}
+
+func truncate(b []byte, n int) string {
+	if len(b) <= n {
+		return string(b)
+	}
+	return string(b[:n])
+}
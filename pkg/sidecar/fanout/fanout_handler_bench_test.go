@@ -0,0 +1,70 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fanout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	duckv1alpha1 "github.com/knative/pkg/apis/duck/v1alpha1"
+	"go.uber.org/zap"
+)
+
+// BenchmarkFanoutHandler_RawForward measures the legacy raw-forwarding dispatch path (payloads
+// that don't decode as CloudEvents), as a baseline to compare against the CloudEvents SDK path.
+func BenchmarkFanoutHandler_RawForward(b *testing.B) {
+	sinkableServer := httptest.NewServer(&fakeHandler{
+		handler: func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		},
+	})
+	defer sinkableServer.Close()
+
+	subs := []duckv1alpha1.ChannelSubscriberSpec{
+		{SinkableDomain: sinkableServer.URL[7:]},
+	}
+	h := NewHandler(zap.NewNop(), Config{Subscriptions: subs}, DefaultClientOptions).(*fanoutHandler)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, cloudEventReq)
+	}
+}
+
+// BenchmarkFanoutHandler_CloudEventBinaryMode measures dispatch through the CloudEvents SDK
+// client pool, using a well-formed CloudEvent so binary-mode negotiation is exercised.
+func BenchmarkFanoutHandler_CloudEventBinaryMode(b *testing.B) {
+	sinkableServer := httptest.NewServer(&fakeHandler{
+		handler: func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		},
+	})
+	defer sinkableServer.Close()
+
+	subs := []duckv1alpha1.ChannelSubscriberSpec{
+		{SinkableDomain: sinkableServer.URL[7:]},
+	}
+	h := NewHandler(zap.NewNop(), Config{Subscriptions: subs}, DefaultClientOptions).(*fanoutHandler)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, ceRequest("channelname.channelnamespace"))
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -37,6 +38,7 @@ import (
 const (
 	replaceCallable = "replaceCallable"
 	replaceSinkable = "replaceSinkable"
+	replaceDLQ      = "replaceDLQ"
 )
 
 var (
@@ -58,12 +60,17 @@ var (
 
 func TestFanoutHandler_ServeHTTP(t *testing.T) {
 	testCases := map[string]struct {
-		receiverFunc   func(buses.ChannelReference, *buses.Message) error
-		timeout        time.Duration
-		subs           []duckv1alpha1.ChannelSubscriberSpec
-		callable       func(http.ResponseWriter, *http.Request)
-		sinkable       func(http.ResponseWriter, *http.Request)
-		expectedStatus int
+		receiverFunc        func(buses.ChannelReference, *buses.Message) error
+		timeout             time.Duration
+		subs                []duckv1alpha1.ChannelSubscriberSpec
+		deliveries          []*DeliverySpec
+		callable            func(http.ResponseWriter, *http.Request)
+		sinkable            func(http.ResponseWriter, *http.Request)
+		dlq                 func(http.ResponseWriter, *http.Request)
+		expectedStatus      int
+		expectDLQCalls      int32
+		expectRetryAfter    string
+		expectFailureHeader bool
 	}{
 		"rejected by receiver": {
 			receiverFunc: func(buses.ChannelReference, *buses.Message) error {
@@ -71,13 +78,6 @@ func TestFanoutHandler_ServeHTTP(t *testing.T) {
 			},
 			expectedStatus: http.StatusInternalServerError,
 		},
-		"could not find tracked message": {
-			receiverFunc: func(buses.ChannelReference, *buses.Message) error {
-				// Not being written to messageStorage.
-				return nil
-			},
-			expectedStatus: http.StatusInternalServerError,
-		},
 		"fanout times out": {
 			timeout: time.Millisecond,
 			subs: []duckv1alpha1.ChannelSubscriberSpec{
@@ -89,7 +89,7 @@ func TestFanoutHandler_ServeHTTP(t *testing.T) {
 				time.Sleep(10 * time.Millisecond)
 				writer.WriteHeader(http.StatusOK)
 			},
-			expectedStatus: http.StatusInternalServerError,
+			expectedStatus: http.StatusGatewayTimeout,
 		},
 		"zero subs succeed": {
 			subs:           []duckv1alpha1.ChannelSubscriberSpec{},
@@ -110,7 +110,7 @@ func TestFanoutHandler_ServeHTTP(t *testing.T) {
 			sinkable: func(writer http.ResponseWriter, _ *http.Request) {
 				writer.WriteHeader(http.StatusNotFound)
 			},
-			expectedStatus: http.StatusInternalServerError,
+			expectedStatus: http.StatusNotFound,
 		},
 		"callable fails": {
 			subs: []duckv1alpha1.ChannelSubscriberSpec{
@@ -121,7 +121,7 @@ func TestFanoutHandler_ServeHTTP(t *testing.T) {
 			callable: func(writer http.ResponseWriter, _ *http.Request) {
 				writer.WriteHeader(http.StatusNotFound)
 			},
-			expectedStatus: http.StatusInternalServerError,
+			expectedStatus: http.StatusNotFound,
 		},
 		"callable succeeds, sinkable fails": {
 			subs: []duckv1alpha1.ChannelSubscriberSpec{
@@ -134,7 +134,7 @@ func TestFanoutHandler_ServeHTTP(t *testing.T) {
 			sinkable: func(writer http.ResponseWriter, _ *http.Request) {
 				writer.WriteHeader(http.StatusForbidden)
 			},
-			expectedStatus: http.StatusInternalServerError,
+			expectedStatus: http.StatusForbidden,
 		},
 		"one sub succeeds": {
 			subs: []duckv1alpha1.ChannelSubscriberSpec{
@@ -162,7 +162,7 @@ func TestFanoutHandler_ServeHTTP(t *testing.T) {
 			},
 			callable:       callableSucceed,
 			sinkable:       (&succeedOnce{}).handler,
-			expectedStatus: http.StatusInternalServerError,
+			expectedStatus: http.StatusForbidden,
 		},
 		"all subs succeed": {
 			subs: []duckv1alpha1.ChannelSubscriberSpec{
@@ -185,6 +185,87 @@ func TestFanoutHandler_ServeHTTP(t *testing.T) {
 			},
 			expectedStatus: http.StatusOK,
 		},
+		"transient sinkable failure succeeds on retry": {
+			subs: []duckv1alpha1.ChannelSubscriberSpec{
+				{SinkableDomain: replaceSinkable},
+			},
+			deliveries: []*DeliverySpec{
+				{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+			},
+			sinkable:       (&failOnceThenSucceed{}).handler,
+			expectedStatus: http.StatusOK,
+		},
+		"exhaustion routed to DLQ": {
+			subs: []duckv1alpha1.ChannelSubscriberSpec{
+				{SinkableDomain: replaceSinkable},
+			},
+			deliveries: []*DeliverySpec{
+				{MaxAttempts: 2, InitialBackoff: time.Millisecond, DeadLetterSink: replaceDLQ},
+			},
+			sinkable: func(writer http.ResponseWriter, _ *http.Request) {
+				writer.WriteHeader(http.StatusServiceUnavailable)
+			},
+			expectedStatus: http.StatusOK,
+			expectDLQCalls: 1,
+		},
+		"non-retryable 4xx routed immediately to DLQ": {
+			subs: []duckv1alpha1.ChannelSubscriberSpec{
+				{SinkableDomain: replaceSinkable},
+			},
+			deliveries: []*DeliverySpec{
+				{MaxAttempts: 5, InitialBackoff: time.Second, DeadLetterSink: replaceDLQ},
+			},
+			sinkable: func(writer http.ResponseWriter, _ *http.Request) {
+				writer.WriteHeader(http.StatusNotFound)
+			},
+			expectedStatus: http.StatusOK,
+			expectDLQCalls: 1,
+		},
+		"DLQ unavailable surfaces 500": {
+			subs: []duckv1alpha1.ChannelSubscriberSpec{
+				{SinkableDomain: replaceSinkable},
+			},
+			deliveries: []*DeliverySpec{
+				{MaxAttempts: 1, DeadLetterSink: replaceDLQ},
+			},
+			sinkable: func(writer http.ResponseWriter, _ *http.Request) {
+				writer.WriteHeader(http.StatusServiceUnavailable)
+			},
+			dlq: func(writer http.ResponseWriter, _ *http.Request) {
+				writer.WriteHeader(http.StatusServiceUnavailable)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectDLQCalls: 1,
+		},
+		"single 429 propagated with Retry-After echoed": {
+			subs: []duckv1alpha1.ChannelSubscriberSpec{
+				{SinkableDomain: replaceSinkable},
+			},
+			deliveries: []*DeliverySpec{
+				{MaxAttempts: 1},
+			},
+			sinkable: func(writer http.ResponseWriter, _ *http.Request) {
+				writer.Header().Set("Retry-After", "120")
+				writer.WriteHeader(http.StatusTooManyRequests)
+			},
+			expectedStatus:      http.StatusTooManyRequests,
+			expectRetryAfter:    "120",
+			expectFailureHeader: true,
+		},
+		"two subs fail with 500 and a failure summary header": {
+			subs: []duckv1alpha1.ChannelSubscriberSpec{
+				{SinkableDomain: replaceSinkable},
+				{CallableDomain: replaceCallable},
+			},
+			sinkable: func(writer http.ResponseWriter, _ *http.Request) {
+				writer.WriteHeader(http.StatusNotFound)
+			},
+			callable: func(writer http.ResponseWriter, _ *http.Request) {
+				writer.WriteHeader(http.StatusNotFound)
+			},
+			expectedStatus:      http.StatusInternalServerError,
+			expectFailureHeader: true,
+		},
 	}
 	for n, tc := range testCases {
 		t.Run(n, func(t *testing.T) {
@@ -196,6 +277,18 @@ func TestFanoutHandler_ServeHTTP(t *testing.T) {
 				handler: tc.sinkable,
 			})
 			defer sinkableServer.Close()
+			var dlqCalls atomic.Int32
+			dlqServer := httptest.NewServer(&fakeHandler{
+				handler: func(w http.ResponseWriter, r *http.Request) {
+					dlqCalls.Inc()
+					if tc.dlq != nil {
+						tc.dlq(w, r)
+						return
+					}
+					w.WriteHeader(http.StatusOK)
+				},
+			})
+			defer dlqServer.Close()
 
 			// Rewrite the subs to use the servers we just started.
 			subs := make([]duckv1alpha1.ChannelSubscriberSpec, 0)
@@ -208,8 +301,17 @@ func TestFanoutHandler_ServeHTTP(t *testing.T) {
 				}
 				subs = append(subs, sub)
 			}
+			deliveries := make([]*DeliverySpec, len(tc.deliveries))
+			for i, d := range tc.deliveries {
+				if d != nil && d.DeadLetterSink == replaceDLQ {
+					withDLQ := *d
+					withDLQ.DeadLetterSink = dlqServer.URL[7:]
+					d = &withDLQ
+				}
+				deliveries[i] = d
+			}
 
-			h := NewHandler(zap.NewNop(), Config{Subscriptions: subs}).(*fanoutHandler)
+			h := NewHandler(zap.NewNop(), Config{Subscriptions: subs, Deliveries: deliveries}, DefaultClientOptions).(*fanoutHandler)
 			if tc.receiverFunc != nil {
 				h.receiver = buses.NewMessageReceiver(tc.receiverFunc, zap.NewNop().Sugar())
 			}
@@ -223,6 +325,15 @@ func TestFanoutHandler_ServeHTTP(t *testing.T) {
 			if w.Code != tc.expectedStatus {
 				t.Errorf("Unexpected status code. Expected %v, Actual %v", tc.expectedStatus, w.Code)
 			}
+			if tc.expectDLQCalls != 0 && dlqCalls.Load() != tc.expectDLQCalls {
+				t.Errorf("Unexpected number of DLQ calls. Expected %v, Actual %v", tc.expectDLQCalls, dlqCalls.Load())
+			}
+			if tc.expectRetryAfter != "" && w.Header().Get("Retry-After") != tc.expectRetryAfter {
+				t.Errorf("Unexpected Retry-After header. Expected %v, Actual %v", tc.expectRetryAfter, w.Header().Get("Retry-After"))
+			}
+			if tc.expectFailureHeader && w.Header().Get("Ce-Knative-Dispatch-Failure") == "" {
+				t.Error("Expected a Ce-Knative-Dispatch-Failure header to be set")
+			}
 		})
 	}
 }
@@ -248,6 +359,20 @@ func (s *succeedOnce) handler(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
+// failOnceThenSucceed returns a retryable failure on its first invocation, and succeeds on every
+// call after that.
+type failOnceThenSucceed struct {
+	called atomic.Bool
+}
+
+func (s *failOnceThenSucceed) handler(w http.ResponseWriter, _ *http.Request) {
+	if s.called.CAS(false, true) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
 func body(body string) io.ReadCloser {
 	return ioutil.NopCloser(strings.NewReader(body))
 }
@@ -255,3 +380,314 @@ func callableSucceed(writer http.ResponseWriter, _ *http.Request) {
 	writer.WriteHeader(http.StatusOK)
 	writer.Write([]byte(cloudEvent))
 }
+
+func TestFanoutHandler_ReplyHeaderFiltering(t *testing.T) {
+	callableServer := httptest.NewServer(&fakeHandler{
+		handler: func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Ce-Id", "1234")
+			w.Header().Set("Ce-Comexampleextension", "custom-value")
+			w.Header().Set("Authorization", "Bearer should-not-forward")
+			w.Header().Set("Set-Cookie", "session=should-not-forward")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(cloudEvent))
+		},
+	})
+	defer callableServer.Close()
+
+	var gotHeaders http.Header
+	sinkableServer := httptest.NewServer(&fakeHandler{
+		handler: func(w http.ResponseWriter, r *http.Request) {
+			gotHeaders = r.Header
+			w.WriteHeader(http.StatusOK)
+		},
+	})
+	defer sinkableServer.Close()
+
+	subs := []duckv1alpha1.ChannelSubscriberSpec{
+		{
+			CallableDomain: callableServer.URL[7:],
+			SinkableDomain: sinkableServer.URL[7:],
+		},
+	}
+	h := NewHandler(zap.NewNop(), Config{Subscriptions: subs}, DefaultClientOptions).(*fanoutHandler)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, cloudEventReq)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code. Expected %v, Actual %v", http.StatusOK, w.Code)
+	}
+
+	if got := gotHeaders.Get("Ce-Id"); got != "1234" {
+		t.Errorf("Ce-Id = %q, want %q", got, "1234")
+	}
+	if got := gotHeaders.Get("Ce-Comexampleextension"); got != "custom-value" {
+		t.Errorf("Ce-Comexampleextension = %q, want %q", got, "custom-value")
+	}
+	if got := gotHeaders.Get("Authorization"); got != "" {
+		t.Errorf("Authorization should have been stripped, got %q", got)
+	}
+	if got := gotHeaders.Get("Set-Cookie"); got != "" {
+		t.Errorf("Set-Cookie should have been stripped, got %q", got)
+	}
+}
+
+// TestFanoutHandler_CallableNotRetriedOnSinkableRetry guards against the Callable leg being
+// re-invoked when only the Sinkable leg needs to retry: a Callable may not be idempotent, so
+// calling it again on every Sinkable retry would duplicate whatever side effect it performs.
+func TestFanoutHandler_CallableNotRetriedOnSinkableRetry(t *testing.T) {
+	var callableCalls atomic.Int32
+	callableServer := httptest.NewServer(&fakeHandler{
+		handler: func(w http.ResponseWriter, _ *http.Request) {
+			callableCalls.Inc()
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(cloudEvent))
+		},
+	})
+	defer callableServer.Close()
+
+	sinkableServer := httptest.NewServer(&fakeHandler{
+		handler: (&failOnceThenSucceed{}).handler,
+	})
+	defer sinkableServer.Close()
+
+	subs := []duckv1alpha1.ChannelSubscriberSpec{
+		{
+			CallableDomain: callableServer.URL[7:],
+			SinkableDomain: sinkableServer.URL[7:],
+		},
+	}
+	deliveries := []*DeliverySpec{
+		{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+	}
+	h := NewHandler(zap.NewNop(), Config{Subscriptions: subs, Deliveries: deliveries}, DefaultClientOptions).(*fanoutHandler)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, cloudEventReq)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code. Expected %v, Actual %v", http.StatusOK, w.Code)
+	}
+	if got := callableCalls.Load(); got != 1 {
+		t.Errorf("Callable was invoked %d times across Sinkable retries, want 1", got)
+	}
+}
+
+func TestFanoutHandler_DeadLetterForwardsHeaders(t *testing.T) {
+	sinkableServer := httptest.NewServer(&fakeHandler{
+		handler: func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		},
+	})
+	defer sinkableServer.Close()
+
+	var gotHeaders http.Header
+	dlqServer := httptest.NewServer(&fakeHandler{
+		handler: func(w http.ResponseWriter, r *http.Request) {
+			gotHeaders = r.Header
+			w.WriteHeader(http.StatusOK)
+		},
+	})
+	defer dlqServer.Close()
+
+	subs := []duckv1alpha1.ChannelSubscriberSpec{
+		{SinkableDomain: sinkableServer.URL[7:]},
+	}
+	deliveries := []*DeliverySpec{
+		{MaxAttempts: 1, DeadLetterSink: dlqServer.URL[7:]},
+	}
+	h := NewHandler(zap.NewNop(), Config{Subscriptions: subs, Deliveries: deliveries}, DefaultClientOptions).(*fanoutHandler)
+
+	req := ceRequest("channelname.channelnamespace")
+	req.Header.Set("Authorization", "Bearer should-not-forward")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code. Expected %v, Actual %v", http.StatusOK, w.Code)
+	}
+
+	for _, header := range []string{"Ce-Id", "Ce-Source", "Ce-Type", "Ce-Specversion", "Content-Type"} {
+		if got := gotHeaders.Get(header); got == "" {
+			t.Errorf("%s was not forwarded to the dead letter sink", header)
+		}
+	}
+	if got := gotHeaders.Get("Authorization"); got != "" {
+		t.Errorf("Authorization should have been stripped, got %q", got)
+	}
+	if got := gotHeaders.Get("Ce-Knative-Dlq-Info"); got == "" {
+		t.Error("Ce-Knative-Dlq-Info metadata header was not set")
+	}
+}
+
+// TestFanoutHandler_CloudEventReplyHeaderFiltering is TestFanoutHandler_ReplyHeaderFiltering's
+// counterpart for a Callable reply dispatched through the CloudEvents SDK path: it asserts that
+// literal transport headers on the Callable's response (not just the reply event's own
+// attributes) are still subject to the allow-list before reaching the Sinkable.
+func TestFanoutHandler_CloudEventReplyHeaderFiltering(t *testing.T) {
+	callableServer := httptest.NewServer(&fakeHandler{
+		handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Ce-Id", "1234")
+			w.Header().Set("Ce-Source", "/mycontext")
+			w.Header().Set("Ce-Type", "com.example.someevent")
+			w.Header().Set("Ce-Specversion", "1.0")
+			w.Header().Set("Traceparent", "00-trace-should-forward")
+			w.Header().Set("Authorization", "Bearer should-not-forward")
+			w.Header().Set("Set-Cookie", "session=should-not-forward")
+			w.WriteHeader(http.StatusOK)
+		},
+	})
+	defer callableServer.Close()
+
+	var gotHeaders http.Header
+	sinkableServer := httptest.NewServer(&fakeHandler{
+		handler: func(w http.ResponseWriter, r *http.Request) {
+			gotHeaders = r.Header
+			w.WriteHeader(http.StatusOK)
+		},
+	})
+	defer sinkableServer.Close()
+
+	subs := []duckv1alpha1.ChannelSubscriberSpec{
+		{
+			CallableDomain: callableServer.URL[7:],
+			SinkableDomain: sinkableServer.URL[7:],
+		},
+	}
+	h := NewHandler(zap.NewNop(), Config{Subscriptions: subs}, DefaultClientOptions).(*fanoutHandler)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, ceRequest("channelname.channelnamespace"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code. Expected %v, Actual %v", http.StatusOK, w.Code)
+	}
+
+	if got := gotHeaders.Get("Traceparent"); got != "00-trace-should-forward" {
+		t.Errorf("Traceparent = %q, want %q", got, "00-trace-should-forward")
+	}
+	if got := gotHeaders.Get("Authorization"); got != "" {
+		t.Errorf("Authorization should have been stripped, got %q", got)
+	}
+	if got := gotHeaders.Get("Set-Cookie"); got != "" {
+		t.Errorf("Set-Cookie should have been stripped, got %q", got)
+	}
+}
+
+// ceRequest builds a request carrying a well-formed, binary-mode CloudEvent, so that it's routed
+// through the CloudEvents SDK dispatch path instead of the raw-forwarding fallback.
+func ceRequest(host string) *http.Request {
+	req := httptest.NewRequest("POST", "http://"+host+"/", body(`"a payload"`))
+	req.Header.Set("Ce-Id", "ce-id-1")
+	req.Header.Set("Ce-Source", "/mycontext")
+	req.Header.Set("Ce-Type", "com.example.someevent")
+	req.Header.Set("Ce-Specversion", "1.0")
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestFanoutHandler_CloudEventBinaryMode(t *testing.T) {
+	var gotHeaders http.Header
+	sinkableServer := httptest.NewServer(&fakeHandler{
+		handler: func(w http.ResponseWriter, r *http.Request) {
+			gotHeaders = r.Header
+			w.WriteHeader(http.StatusOK)
+		},
+	})
+	defer sinkableServer.Close()
+
+	subs := []duckv1alpha1.ChannelSubscriberSpec{
+		{SinkableDomain: sinkableServer.URL[7:]},
+	}
+	h := NewHandler(zap.NewNop(), Config{Subscriptions: subs}, DefaultClientOptions).(*fanoutHandler)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, ceRequest("channelname.channelnamespace"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code. Expected %v, Actual %v", http.StatusOK, w.Code)
+	}
+
+	for _, header := range []string{"Ce-Id", "Ce-Source", "Ce-Type", "Ce-Specversion"} {
+		if got := gotHeaders.Get(header); got == "" {
+			t.Errorf("%s was not set on the outbound binary-mode request", header)
+		}
+	}
+}
+
+func TestFanoutHandler_CloudEventStructuredModeFallback(t *testing.T) {
+	var gotContentType string
+	sinkableServer := httptest.NewServer(&fakeHandler{
+		handler: func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Ce-Specversion") != "" {
+				// Still being offered binary mode: tell the client it needs structured mode.
+				gotContentType = r.Header.Get("Content-Type")
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				return
+			}
+			gotContentType = r.Header.Get("Content-Type")
+			w.WriteHeader(http.StatusOK)
+		},
+	})
+	defer sinkableServer.Close()
+
+	subs := []duckv1alpha1.ChannelSubscriberSpec{
+		{SinkableDomain: sinkableServer.URL[7:]},
+	}
+	h := NewHandler(zap.NewNop(), Config{Subscriptions: subs}, DefaultClientOptions).(*fanoutHandler)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, ceRequest("channelname.channelnamespace"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code. Expected %v, Actual %v", http.StatusOK, w.Code)
+	}
+	if gotContentType != "application/cloudevents+json" {
+		t.Errorf("Structured-mode fallback Content-Type = %q, want application/cloudevents+json", gotContentType)
+	}
+}
+
+// TestFanoutHandler_ServeHTTP_ConcurrentRequests guards against the two concurrent ServeHTTP
+// calls for the same Channel clobbering each other's in-flight Message: ServeHTTP must fan out
+// whatever HandleRequest handed back, never a Message some other goroutine stored.
+func TestFanoutHandler_ServeHTTP_ConcurrentRequests(t *testing.T) {
+	received := make(chan string, 2)
+	sinkableServer := httptest.NewServer(&fakeHandler{
+		handler: func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				t.Errorf("unable to read forwarded body: %v", err)
+			}
+			received <- string(body)
+			w.WriteHeader(http.StatusOK)
+		},
+	})
+	defer sinkableServer.Close()
+
+	subs := []duckv1alpha1.ChannelSubscriberSpec{
+		{SinkableDomain: sinkableServer.URL[7:]},
+	}
+	h := NewHandler(zap.NewNop(), Config{Subscriptions: subs}, DefaultClientOptions).(*fanoutHandler)
+
+	var wg sync.WaitGroup
+	payloads := []string{"payload-one", "payload-two"}
+	for _, payload := range payloads {
+		wg.Add(1)
+		go func(payload string) {
+			defer wg.Done()
+			req := httptest.NewRequest("POST", "http://channelname.channelnamespace/", body(payload))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Errorf("Unexpected status code for %q. Expected %v, Actual %v", payload, http.StatusOK, w.Code)
+			}
+		}(payload)
+	}
+	wg.Wait()
+	close(received)
+
+	seen := make(map[string]bool, len(payloads))
+	for body := range received {
+		seen[body] = true
+	}
+	for _, payload := range payloads {
+		if !seen[payload] {
+			t.Errorf("Sinkable never received %q; concurrent requests clobbered each other", payload)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fanout
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ceHeaderPrefix is the prefix used by the CloudEvents HTTP binding for all binary-mode
+// attribute headers (Ce-Id, Ce-Source, Ce-Type, Ce-Knative-Whatever, ...). Any header with this
+// prefix is always allow-listed, since it is, by definition, part of the event.
+const ceHeaderPrefix = "Ce-"
+
+// defaultAllowedReplyHeaders are forwarded from a Callable's response to the Sinkable even
+// without being named in Config.AllowedReplyHeaders. This mirrors the allow-list used by the
+// broker's filter handler: CloudEvents attribute headers and the handful of standard headers
+// needed for correct content negotiation and tracing propagate by default, while anything that
+// looks like an auth or session header does not.
+var defaultAllowedReplyHeaders = []string{
+	"Content-Type",
+	"Traceparent",
+	"Tracestate",
+	"Prefer",
+}
+
+// neverAllowedReplyHeaders are dropped even when named in Config.AllowedReplyHeaders.
+var neverAllowedReplyHeaders = map[string]bool{
+	"Authorization": true, "Cookie": true, "Set-Cookie": true,
+	"Connection": true, "Keep-Alive": true, "Proxy-Authenticate": true, "Proxy-Authorization": true,
+	"Te": true, "Trailer": true, "Transfer-Encoding": true, "Upgrade": true,
+}
+
+// HeaderProxyAllowList decides which HTTP headers on a Callable's reply are forwarded to the
+// Sinkable. Headers not on the allow-list (notably Authorization, Cookie, Set-Cookie, and
+// hop-by-hop headers) are always dropped, regardless of configuration.
+type HeaderProxyAllowList struct {
+	allowed map[string]bool
+}
+
+// NewHeaderProxyAllowList builds a HeaderProxyAllowList from the package default plus any
+// additional header names configured for a Channel.
+func NewHeaderProxyAllowList(additional []string) HeaderProxyAllowList {
+	allowed := make(map[string]bool, len(defaultAllowedReplyHeaders)+len(additional))
+	for _, h := range defaultAllowedReplyHeaders {
+		allowed[http.CanonicalHeaderKey(h)] = true
+	}
+	for _, h := range additional {
+		if canonical := http.CanonicalHeaderKey(h); !neverAllowedReplyHeaders[canonical] {
+			allowed[canonical] = true
+		}
+	}
+	return HeaderProxyAllowList{allowed: allowed}
+}
+
+// Allow reports whether the named header may be proxied from a Callable's reply to the Sinkable.
+func (l HeaderProxyAllowList) Allow(name string) bool {
+	canonical := http.CanonicalHeaderKey(name)
+	if strings.HasPrefix(canonical, ceHeaderPrefix) {
+		return true
+	}
+	return l.allowed[canonical]
+}
+
+// Filter returns the subset of headers permitted by the allow-list.
+func (l HeaderProxyAllowList) Filter(headers http.Header) http.Header {
+	filtered := make(http.Header, len(headers))
+	for name, values := range headers {
+		if l.Allow(name) {
+			filtered[name] = values
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fanout
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHeaderProxyAllowList_Allow(t *testing.T) {
+	testCases := map[string]struct {
+		additional []string
+		header     string
+		want       bool
+	}{
+		"default Content-Type allowed":  {header: "Content-Type", want: true},
+		"default Traceparent allowed":   {header: "Traceparent", want: true},
+		"Ce- attribute allowed":         {header: "Ce-Id", want: true},
+		"custom Ce- extension allowed":  {header: "Ce-Comexampleextension", want: true},
+		"Authorization always stripped": {header: "Authorization", want: false},
+		"Cookie always stripped":        {header: "Cookie", want: false},
+		"Set-Cookie always stripped":    {header: "Set-Cookie", want: false},
+		"arbitrary header stripped":     {header: "X-Something-Else", want: false},
+		"configured additional allowed": {additional: []string{"X-Something-Else"}, header: "X-Something-Else", want: true},
+		"additional does not leak":      {additional: []string{"X-Something-Else"}, header: "Authorization", want: false},
+	}
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			allowList := NewHeaderProxyAllowList(tc.additional)
+			if got := allowList.Allow(tc.header); got != tc.want {
+				t.Errorf("Allow(%q) = %v, want %v", tc.header, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHeaderProxyAllowList_Filter(t *testing.T) {
+	allowList := NewHeaderProxyAllowList(nil)
+	headers := http.Header{
+		"Ce-Id":         []string{"1234"},
+		"Content-Type":  []string{"application/json"},
+		"Authorization": []string{"Bearer secret"},
+		"Cookie":        []string{"session=secret"},
+	}
+	filtered := allowList.Filter(headers)
+	if _, ok := filtered["Authorization"]; ok {
+		t.Error("Authorization should have been stripped")
+	}
+	if _, ok := filtered["Cookie"]; ok {
+		t.Error("Cookie should have been stripped")
+	}
+	if got := filtered.Get("Ce-Id"); got != "1234" {
+		t.Errorf("Ce-Id = %q, want %q", got, "1234")
+	}
+	if got := filtered.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want %q", got, "application/json")
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package multichannelfanout provides an http.Handler that takes in one request and sends it to
+// the fanout.Handler for the Channel the request is addressed to, based on the request's Host
+// header. It allows a single dispatcher process to serve every Channel in the cluster.
+package multichannelfanout
+
+import (
+	"fmt"
+
+	"github.com/knative/eventing/pkg/sidecar/fanout"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Config is the full set of Channels a dispatcher process is currently serving, along with each
+// Channel's fanout.Config.
+type Config struct {
+	ChannelConfigs []ChannelConfig
+}
+
+// ChannelConfig is everything needed to route and fan out to a single Channel.
+type ChannelConfig struct {
+	Namespace string
+	Name      string
+	// HostName is the Channel's externally addressable hostname; incoming requests are routed
+	// to this ChannelConfig's FanoutConfig based on matching the request's Host header.
+	HostName string
+
+	FanoutConfig fanout.Config
+}
+
+// hasSubscription reports whether this ChannelConfig contains a Subscription whose UID is uid.
+func (c ChannelConfig) hasSubscription(uid types.UID) bool {
+	for _, sub := range c.FanoutConfig.Subscriptions {
+		if sub.UID == uid {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigDiff diffs the current Config against other, returning a human readable description of
+// the difference, or the empty string if they are equivalent. It is used to avoid unnecessary
+// Handler swaps when a reconcile loop observes no meaningful change.
+func (c Config) ConfigDiff(other Config) string {
+	if len(c.ChannelConfigs) != len(other.ChannelConfigs) {
+		return fmt.Sprintf("channel count changed: %d -> %d", len(c.ChannelConfigs), len(other.ChannelConfigs))
+	}
+	for i := range c.ChannelConfigs {
+		if !c.ChannelConfigs[i].equal(other.ChannelConfigs[i]) {
+			return fmt.Sprintf("channel %d changed", i)
+		}
+	}
+	return ""
+}
+
+// equal reports whether c and other would produce the same fanout.Handler behaviour, so
+// ConfigDiff only triggers a Handler swap on a meaningful change.
+func (c ChannelConfig) equal(other ChannelConfig) bool {
+	if c.Namespace != other.Namespace || c.Name != other.Name || c.HostName != other.HostName {
+		return false
+	}
+	if len(c.FanoutConfig.Subscriptions) != len(other.FanoutConfig.Subscriptions) {
+		return false
+	}
+	for i, sub := range c.FanoutConfig.Subscriptions {
+		o := other.FanoutConfig.Subscriptions[i]
+		if sub.UID != o.UID || sub.CallableDomain != o.CallableDomain || sub.SinkableDomain != o.SinkableDomain {
+			return false
+		}
+		if deliverySpecAt(c.FanoutConfig.Deliveries, i) != deliverySpecAt(other.FanoutConfig.Deliveries, i) {
+			return false
+		}
+	}
+	return stringsEqual(c.FanoutConfig.AllowedReplyHeaders, other.FanoutConfig.AllowedReplyHeaders)
+}
+
+// deliverySpecAt returns the DeliverySpec deliveries holds for subscription i, falling back to
+// fanout.DefaultDeliverySpec the same way fanout.Config itself does, so equal can't mistake a
+// nil/short Deliveries entry for a genuine difference from an explicit default DeliverySpec.
+func deliverySpecAt(deliveries []*fanout.DeliverySpec, i int) fanout.DeliverySpec {
+	if i < len(deliveries) && deliveries[i] != nil {
+		return *deliveries[i]
+	}
+	return fanout.DefaultDeliverySpec
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, v := range a {
+		if v != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package multichannelfanout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/knative/eventing/pkg/sidecar/fanout"
+	duckv1alpha1 "github.com/knative/pkg/apis/duck/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestConfig_ConfigDiff(t *testing.T) {
+	base := Config{
+		ChannelConfigs: []ChannelConfig{
+			{
+				Namespace: "ns",
+				Name:      "my-channel",
+				HostName:  "my-channel.ns.svc.cluster.local",
+				FanoutConfig: fanout.Config{
+					Subscriptions: []duckv1alpha1.ChannelSubscriberSpec{
+						{UID: types.UID("sub-uid"), CallableDomain: "callable.ns.svc.cluster.local", SinkableDomain: "sink.ns.svc.cluster.local"},
+					},
+					Deliveries: []*fanout.DeliverySpec{
+						{MaxAttempts: 1},
+					},
+					AllowedReplyHeaders: []string{"X-My-Header"},
+				},
+			},
+		},
+	}
+
+	testCases := map[string]struct {
+		modify   func(Config) Config
+		wantDiff bool
+	}{
+		"identical config": {
+			modify:   func(c Config) Config { return c },
+			wantDiff: false,
+		},
+		"channel count changed": {
+			modify: func(c Config) Config {
+				c.ChannelConfigs = append(c.ChannelConfigs, c.ChannelConfigs[0])
+				return c
+			},
+			wantDiff: true,
+		},
+		"only Deliveries changed": {
+			modify: func(c Config) Config {
+				c.ChannelConfigs[0].FanoutConfig.Deliveries = []*fanout.DeliverySpec{
+					{MaxAttempts: 5, InitialBackoff: time.Second, DeadLetterSink: "dlq.ns.svc.cluster.local"},
+				}
+				return c
+			},
+			wantDiff: true,
+		},
+		"only AllowedReplyHeaders changed": {
+			modify: func(c Config) Config {
+				c.ChannelConfigs[0].FanoutConfig.AllowedReplyHeaders = []string{"X-Other-Header"}
+				return c
+			},
+			wantDiff: true,
+		},
+	}
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			other := copyConfig(base)
+			other = tc.modify(other)
+			if diff := base.ConfigDiff(other); (diff != "") != tc.wantDiff {
+				t.Errorf("ConfigDiff() = %q, want non-empty: %v", diff, tc.wantDiff)
+			}
+		})
+	}
+}
+
+// copyConfig returns a deep-enough copy of c that a test can mutate its ChannelConfigs,
+// Deliveries, and AllowedReplyHeaders without the change being visible through c itself.
+func copyConfig(c Config) Config {
+	cc := make([]ChannelConfig, len(c.ChannelConfigs))
+	for i, orig := range c.ChannelConfigs {
+		orig.FanoutConfig.Subscriptions = append([]duckv1alpha1.ChannelSubscriberSpec(nil), orig.FanoutConfig.Subscriptions...)
+		orig.FanoutConfig.Deliveries = append([]*fanout.DeliverySpec(nil), orig.FanoutConfig.Deliveries...)
+		orig.FanoutConfig.AllowedReplyHeaders = append([]string(nil), orig.FanoutConfig.AllowedReplyHeaders...)
+		cc[i] = orig
+	}
+	return Config{ChannelConfigs: cc}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package multichannelfanout
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/knative/eventing/pkg/sidecar/fanout"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ProbePath is the path dispatcher pods expose to let the control plane ask whether a particular
+// Subscription has been loaded into this process' in-memory Config yet.
+const ProbePath = "/healthz/subscription"
+
+const (
+	channelQueryParam = "channel"
+	subQueryParam     = "sub"
+)
+
+// Handler is an http.Handler that routes each request, by Host header, to the fanout.Handler for
+// the Channel it was addressed to.
+type Handler struct {
+	logger *zap.Logger
+	config Config
+
+	fanoutHandlers map[string]http.Handler
+	channels       map[channelKey]ChannelConfig
+}
+
+type channelKey struct {
+	namespace string
+	name      string
+}
+
+// NewHandler creates a new Handler for config.
+func NewHandler(logger *zap.Logger, config Config) (*Handler, error) {
+	fanoutHandlers := make(map[string]http.Handler, len(config.ChannelConfigs))
+	channels := make(map[channelKey]ChannelConfig, len(config.ChannelConfigs))
+	for _, cc := range config.ChannelConfigs {
+		fanoutHandlers[cc.HostName] = fanout.NewHandler(logger, cc.FanoutConfig, fanout.DefaultClientOptions)
+		channels[channelKey{namespace: cc.Namespace, name: cc.Name}] = cc
+	}
+	return &Handler{
+		logger:         logger,
+		config:         config,
+		fanoutHandlers: fanoutHandlers,
+		channels:       channels,
+	}, nil
+}
+
+// Config returns the Config this Handler was created with.
+func (h *Handler) Config() Config {
+	return h.config
+}
+
+// ConfigDiff returns a human readable description of the difference between h's Config and
+// other, or the empty string if they are equivalent.
+func (h *Handler) ConfigDiff(other Config) string {
+	return h.config.ConfigDiff(other)
+}
+
+// CopyWithNewConfig returns a new Handler for config, leaving h untouched.
+func (h *Handler) CopyWithNewConfig(config Config) (*Handler, error) {
+	return NewHandler(h.logger, config)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == ProbePath {
+		h.serveProbe(w, r)
+		return
+	}
+
+	fh, ok := h.fanoutHandlers[r.Host]
+	if !ok {
+		h.logger.Debug("Unable to find a fanout handler for Host", zap.String("host", r.Host))
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	fh.ServeHTTP(w, r)
+}
+
+// serveProbe answers ProbePath requests: it returns 200 only if this Handler's Config already
+// contains the Channel named by the 'channel' query parameter (ns/name), and that Channel's
+// Subscriptions include the 'sub' query parameter's UID. Either condition failing to hold reports
+// not-ready: either this process hasn't been swapped to the Config that added the Subscription
+// yet, or it has but the particular Subscription being probed isn't (yet, or any longer) part of
+// it.
+func (h *Handler) serveProbe(w http.ResponseWriter, r *http.Request) {
+	channel := r.URL.Query().Get(channelQueryParam)
+	sub := r.URL.Query().Get(subQueryParam)
+
+	nsName := strings.SplitN(channel, "/", 2)
+	if len(nsName) != 2 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	cc, ok := h.channels[channelKey{namespace: nsName[0], name: nsName[1]}]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if !cc.hasSubscription(types.UID(sub)) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
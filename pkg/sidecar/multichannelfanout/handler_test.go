@@ -0,0 +1,81 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package multichannelfanout
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/knative/eventing/pkg/sidecar/fanout"
+	duckv1alpha1 "github.com/knative/pkg/apis/duck/v1alpha1"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestHandler_ServeProbe(t *testing.T) {
+	installed := Config{
+		ChannelConfigs: []ChannelConfig{
+			{
+				Namespace: "ns",
+				Name:      "my-channel",
+				HostName:  "my-channel.ns.svc.cluster.local",
+				FanoutConfig: fanout.Config{
+					Subscriptions: []duckv1alpha1.ChannelSubscriberSpec{
+						{UID: types.UID("present-uid")},
+					},
+				},
+			},
+		},
+	}
+
+	testCases := map[string]struct {
+		channel      string
+		sub          string
+		expectedCode int
+	}{
+		"config not yet swapped": {
+			channel:      "ns/not-yet-installed-channel",
+			sub:          "present-uid",
+			expectedCode: 404,
+		},
+		"config swapped but sub missing": {
+			channel:      "ns/my-channel",
+			sub:          "missing-uid",
+			expectedCode: 404,
+		},
+		"sub present": {
+			channel:      "ns/my-channel",
+			sub:          "present-uid",
+			expectedCode: 200,
+		},
+	}
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			h, err := NewHandler(zap.NewNop(), installed)
+			if err != nil {
+				t.Fatalf("NewHandler() = %v", err)
+			}
+
+			req := httptest.NewRequest("GET", ProbePath+"?channel="+tc.channel+"&sub="+tc.sub, nil)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != tc.expectedCode {
+				t.Errorf("Unexpected status code. Expected %v, Actual %v", tc.expectedCode, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2018 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package swappable provides an http.Handler that wraps a multichannelfanout.Handler, allowing
+// it to be replaced wholesale every time the set of Channels or Subscriptions changes, without
+// ever serving a request against a half constructed multichannelfanout.Handler.
+package swappable
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/knative/eventing/pkg/sidecar/multichannelfanout"
+	"go.uber.org/zap"
+)
+
+// Handler is an http.Handler that delegates every request to whatever multichannelfanout.Handler
+// was most recently installed with SetMultiChannelFanoutHandler.
+type Handler struct {
+	logger *zap.Logger
+	// handler is a *multichannelfanout.Handler, stored as an atomic.Value so that ServeHTTP
+	// never observes a Handler mid-swap.
+	handler atomic.Value
+}
+
+// NewEmptyHandler creates a Handler with no Channels configured. SetMultiChannelFanoutHandler
+// must be called before it will serve any Channel's traffic.
+func NewEmptyHandler(logger *zap.Logger) (*Handler, error) {
+	mch, err := multichannelfanout.NewHandler(logger, multichannelfanout.Config{})
+	if err != nil {
+		return nil, err
+	}
+	h := &Handler{
+		logger: logger,
+	}
+	h.handler.Store(mch)
+	return h, nil
+}
+
+// GetMultiChannelFanoutHandler returns the multichannelfanout.Handler currently installed.
+func (h *Handler) GetMultiChannelFanoutHandler() *multichannelfanout.Handler {
+	return h.handler.Load().(*multichannelfanout.Handler)
+}
+
+// SetMultiChannelFanoutHandler atomically replaces the multichannelfanout.Handler serving
+// traffic.
+func (h *Handler) SetMultiChannelFanoutHandler(mch *multichannelfanout.Handler) {
+	h.handler.Store(mch)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.GetMultiChannelFanoutHandler().ServeHTTP(w, r)
+}